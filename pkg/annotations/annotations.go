@@ -0,0 +1,146 @@
+// Package annotations parses the per-Ingress behavior this controller
+// supports via metadata.annotations, so the watcher only has to do it once
+// and the router/proxy layer can act on the resulting Config directly.
+package annotations
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Prefix namespaces every annotation this controller understands, matching
+// the default IngressClass controller name.
+const Prefix = "simple.io/"
+
+const (
+	rewriteTargetKey  = Prefix + "rewrite-target"
+	stripPrefixKey    = Prefix + "strip-prefix"
+	addPrefixKey      = Prefix + "add-prefix"
+	responseHeaders   = Prefix + "custom-response-headers"
+	allowCIDRKey      = Prefix + "whitelist-source-range"
+	denyCIDRKey       = Prefix + "denylist-source-range"
+	basicAuthSecret   = Prefix + "auth-secret"
+	backendProtocol   = Prefix + "backend-protocol"
+	connectTimeoutKey = Prefix + "proxy-connect-timeout"
+	readTimeoutKey    = Prefix + "proxy-read-timeout"
+	sendTimeoutKey    = Prefix + "proxy-send-timeout"
+)
+
+// defaultBackendProtocol is used when backendProtocol isn't set.
+const defaultBackendProtocol = "http"
+
+// Config is the parsed, ready-to-use form of an Ingress's annotations.
+type Config struct {
+	// RewriteTarget replaces the matched path before it's proxied
+	// upstream, e.g. "/$2" alongside a capturing Prefix path.
+	RewriteTarget string
+	// StripPrefix, if set, is removed from the start of the request path
+	// before proxying.
+	StripPrefix string
+	// AddPrefix, if set, is prepended to the request path before proxying.
+	AddPrefix string
+
+	// ResponseHeaders are added to every response for this Ingress.
+	ResponseHeaders map[string]string
+
+	// AllowCIDRs, if non-empty, restricts access to matching source IPs.
+	AllowCIDRs []*net.IPNet
+	// DenyCIDRs blocks access from matching source IPs.
+	DenyCIDRs []*net.IPNet
+
+	// BasicAuthSecret is a "namespace/name" Secret reference holding
+	// htpasswd-style credentials to require for this Ingress.
+	BasicAuthSecret string
+
+	// BackendProtocol is "http" (default) or "https".
+	BackendProtocol string
+
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	SendTimeout    time.Duration
+}
+
+// Parse reads ingressAnnotations (an Ingress's metadata.annotations) into a
+// Config, logging and ignoring individual malformed values rather than
+// failing the whole Ingress.
+func Parse(ingressAnnotations map[string]string) Config {
+	cfg := Config{
+		BackendProtocol: defaultBackendProtocol,
+	}
+
+	cfg.RewriteTarget = ingressAnnotations[rewriteTargetKey]
+	cfg.StripPrefix = ingressAnnotations[stripPrefixKey]
+	cfg.AddPrefix = ingressAnnotations[addPrefixKey]
+	cfg.BasicAuthSecret = ingressAnnotations[basicAuthSecret]
+
+	if proto, ok := ingressAnnotations[backendProtocol]; ok && proto != "" {
+		cfg.BackendProtocol = strings.ToLower(proto)
+	}
+
+	if value, ok := ingressAnnotations[responseHeaders]; ok {
+		cfg.ResponseHeaders = parseHeaders(value)
+	}
+
+	if value, ok := ingressAnnotations[allowCIDRKey]; ok {
+		cfg.AllowCIDRs = parseCIDRs(value)
+	}
+	if value, ok := ingressAnnotations[denyCIDRKey]; ok {
+		cfg.DenyCIDRs = parseCIDRs(value)
+	}
+
+	cfg.ConnectTimeout = parseDuration(ingressAnnotations[connectTimeoutKey])
+	cfg.ReadTimeout = parseDuration(ingressAnnotations[readTimeoutKey])
+	cfg.SendTimeout = parseDuration(ingressAnnotations[sendTimeoutKey])
+
+	return cfg
+}
+
+// parseHeaders parses "Name: Value,Name2: Value2" into a map.
+func parseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		name, val, found := strings.Cut(pair, ":")
+		if !found {
+			log.Warn().Str("value", pair).Msg("ignoring malformed custom response header")
+			continue
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(val)
+	}
+	return headers
+}
+
+// parseCIDRs parses a comma-separated list of CIDRs, skipping and logging
+// any entry that doesn't parse.
+func parseCIDRs(value string) []*net.IPNet {
+	var cidrs []*net.IPNet
+	for _, raw := range strings.Split(value, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(raw)
+		if err != nil {
+			log.Warn().Err(err).Str("value", raw).Msg("ignoring malformed CIDR")
+			continue
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs
+}
+
+// parseDuration parses a Go duration string, returning zero (meaning "use
+// the proxy's default") for an empty or malformed value.
+func parseDuration(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Warn().Err(err).Str("value", value).Msg("ignoring malformed timeout")
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,95 @@
+package annotations
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        Config
+	}{
+		{
+			name:        "defaults when no annotations set",
+			annotations: nil,
+			want:        Config{BackendProtocol: "http"},
+		},
+		{
+			name: "simple string fields",
+			annotations: map[string]string{
+				rewriteTargetKey: "/$2",
+				stripPrefixKey:   "/api",
+				addPrefixKey:     "/v1",
+				basicAuthSecret:  "default/creds",
+				backendProtocol:  "HTTPS",
+			},
+			want: Config{
+				RewriteTarget:   "/$2",
+				StripPrefix:     "/api",
+				AddPrefix:       "/v1",
+				BasicAuthSecret: "default/creds",
+				BackendProtocol: "https",
+			},
+		},
+		{
+			name: "response headers parsed and malformed pairs skipped",
+			annotations: map[string]string{
+				responseHeaders: "X-Frame-Options: DENY, malformed-entry, X-Foo:  bar ",
+			},
+			want: Config{
+				BackendProtocol: "http",
+				ResponseHeaders: map[string]string{
+					"X-Frame-Options": "DENY",
+					"X-Foo":           "bar",
+				},
+			},
+		},
+		{
+			name: "malformed CIDR entries are skipped",
+			annotations: map[string]string{
+				allowCIDRKey: "10.0.0.0/8, not-a-cidr, 192.168.1.0/24",
+			},
+			want: Config{
+				BackendProtocol: "http",
+				AllowCIDRs:      mustParseCIDRs(t, "10.0.0.0/8", "192.168.1.0/24"),
+			},
+		},
+		{
+			name: "malformed timeout falls back to zero",
+			annotations: map[string]string{
+				connectTimeoutKey: "not-a-duration",
+				readTimeoutKey:    "5s",
+			},
+			want: Config{
+				BackendProtocol: "http",
+				ReadTimeout:     5 * time.Second,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.annotations)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%v) = %+v, want %+v", tt.annotations, got, tt.want)
+			}
+		})
+	}
+}
+
+func mustParseCIDRs(t *testing.T, raws ...string) []*net.IPNet {
+	t.Helper()
+	cidrs := make([]*net.IPNet, 0, len(raws))
+	for _, raw := range raws {
+		_, cidr, err := net.ParseCIDR(raw)
+		if err != nil {
+			t.Fatalf("ParseCIDR(%q): %v", raw, err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs
+}
@@ -0,0 +1,199 @@
+// Package status patches the status.loadBalancer.ingress field of managed
+// Ingresses, mirroring Traefik's IngressEndpoint option.
+package status
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bep/debounce"
+	"github.com/rs/zerolog/log"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/AaronPei/kubernetes-simple-ingress-controller/watcher"
+)
+
+// Config configures how the published endpoint is resolved. Either set IP
+// and/or Hostname directly, or set PublishService to a "namespace/name"
+// reference and have the endpoint resolved from that Service's own
+// status.loadBalancer.ingress on every publish.
+type Config struct {
+	IP             string
+	Hostname       string
+	PublishService string
+}
+
+// A Publisher watches for Payload changes and writes status.loadBalancer
+// onto every managed Ingress, so kubectl get ingress shows the address
+// traffic actually arrives on.
+type Publisher struct {
+	client kubernetes.Interface
+	config Config
+
+	// isLeader gates the actual writes so only one replica of a
+	// multi-instance deployment patches status at a time. Defaults to
+	// always-leader; WithLeaderGate overrides it (e.g. with
+	// Watcher.IsLeader).
+	isLeader func() bool
+
+	// useNetworkingV1 records whether the cluster serves
+	// networking.k8s.io/v1, detected once in New the same way
+	// watcher.Watcher does; clusters that don't fall back to patching
+	// status via networking.k8s.io/v1beta1 instead.
+	useNetworkingV1 bool
+
+	debounced func(func())
+
+	mu      sync.Mutex
+	payload *watcher.Payload
+}
+
+// New creates a Publisher that patches Ingress status using client.
+func New(client kubernetes.Interface, config Config) *Publisher {
+	p := &Publisher{
+		client:          client,
+		config:          config,
+		isLeader:        func() bool { return true },
+		useNetworkingV1: true,
+		debounced:       debounce.New(time.Second),
+	}
+	if _, err := client.Discovery().ServerResourcesForGroupVersion(networkingv1.SchemeGroupVersion.String()); err != nil {
+		log.Warn().Err(err).Msg("networking.k8s.io/v1 not available on this cluster, patching ingress status via v1beta1")
+		p.useNetworkingV1 = false
+	}
+	return p
+}
+
+// WithLeaderGate makes the Publisher only write status while isLeader
+// reports true, for HA deployments with several controller replicas.
+func (p *Publisher) WithLeaderGate(isLeader func() bool) *Publisher {
+	p.isLeader = isLeader
+	return p
+}
+
+// Update records the latest Payload and debounces a status patch across
+// every Ingress it contains, the same way Watcher debounces onChange.
+func (p *Publisher) Update(ctx context.Context, payload *watcher.Payload) {
+	p.mu.Lock()
+	p.payload = payload
+	p.mu.Unlock()
+
+	p.debounced(func() {
+		p.publish(ctx)
+	})
+}
+
+func (p *Publisher) publish(ctx context.Context) {
+	if !p.isLeader() {
+		return
+	}
+
+	p.mu.Lock()
+	payload := p.payload
+	p.mu.Unlock()
+	if payload == nil {
+		return
+	}
+
+	lbIngress, err := p.resolve(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to resolve ingress publish endpoint")
+		return
+	}
+
+	for _, ingressPayload := range payload.Ingresses {
+		ing := ingressPayload.Ingress
+		if p.useNetworkingV1 {
+			p.publishV1(ctx, ing, lbIngress)
+		} else {
+			p.publishV1beta1(ctx, ing, lbIngress)
+		}
+	}
+}
+
+func (p *Publisher) publishV1(ctx context.Context, ing *networkingv1.Ingress, lbIngress []networkingv1.IngressLoadBalancerIngress) {
+	current, err := p.client.NetworkingV1().Ingresses(ing.Namespace).Get(ctx, ing.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Error().Err(err).
+			Str("namespace", ing.Namespace).
+			Str("name", ing.Name).
+			Msg("failed to fetch ingress before status patch")
+		return
+	}
+
+	current.Status.LoadBalancer.Ingress = lbIngress
+	if _, err := p.client.NetworkingV1().Ingresses(ing.Namespace).UpdateStatus(ctx, current, metav1.UpdateOptions{}); err != nil {
+		log.Error().Err(err).
+			Str("namespace", ing.Namespace).
+			Str("name", ing.Name).
+			Msg("failed to patch ingress status")
+	}
+}
+
+// publishV1beta1 is publishV1's counterpart for clusters that don't serve
+// networking.k8s.io/v1; the Ingress the watcher hands us is always the v1
+// shape (buildIngressPayload converts on the way in), so only the API call
+// and the status field's type change.
+func (p *Publisher) publishV1beta1(ctx context.Context, ing *networkingv1.Ingress, lbIngress []networkingv1.IngressLoadBalancerIngress) {
+	current, err := p.client.NetworkingV1beta1().Ingresses(ing.Namespace).Get(ctx, ing.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Error().Err(err).
+			Str("namespace", ing.Namespace).
+			Str("name", ing.Name).
+			Msg("failed to fetch ingress before status patch")
+		return
+	}
+
+	current.Status.LoadBalancer.Ingress = toV1beta1LoadBalancerIngress(lbIngress)
+	if _, err := p.client.NetworkingV1beta1().Ingresses(ing.Namespace).UpdateStatus(ctx, current, metav1.UpdateOptions{}); err != nil {
+		log.Error().Err(err).
+			Str("namespace", ing.Namespace).
+			Str("name", ing.Name).
+			Msg("failed to patch ingress status")
+	}
+}
+
+// toV1beta1LoadBalancerIngress translates the v1 LoadBalancer ingress
+// points into the networking.k8s.io/v1beta1 IngressStatus shape.
+func toV1beta1LoadBalancerIngress(in []networkingv1.IngressLoadBalancerIngress) []networkingv1beta1.IngressLoadBalancerIngress {
+	out := make([]networkingv1beta1.IngressLoadBalancerIngress, 0, len(in))
+	for _, lb := range in {
+		out = append(out, networkingv1beta1.IngressLoadBalancerIngress{IP: lb.IP, Hostname: lb.Hostname})
+	}
+	return out
+}
+
+// resolve returns the LoadBalancer ingress points to publish, either the
+// statically configured IP/Hostname or the live status of PublishService.
+func (p *Publisher) resolve(ctx context.Context) ([]networkingv1.IngressLoadBalancerIngress, error) {
+	if p.config.PublishService == "" {
+		return []networkingv1.IngressLoadBalancerIngress{{
+			IP:       p.config.IP,
+			Hostname: p.config.Hostname,
+		}}, nil
+	}
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(p.config.PublishService)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := p.client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]networkingv1.IngressLoadBalancerIngress, 0, len(svc.Status.LoadBalancer.Ingress))
+	for _, lb := range svc.Status.LoadBalancer.Ingress {
+		result = append(result, networkingv1.IngressLoadBalancerIngress{
+			IP:       lb.IP,
+			Hostname: lb.Hostname,
+		})
+	}
+	return result, nil
+}
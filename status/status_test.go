@@ -0,0 +1,75 @@
+package status
+
+import (
+	"context"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/AaronPei/kubernetes-simple-ingress-controller/watcher"
+)
+
+func TestPublish_V1beta1Fallback(t *testing.T) {
+	// A fake clientset's discovery reports no resources by default, the
+	// same as a cluster that doesn't serve networking.k8s.io/v1.
+	client := fake.NewSimpleClientset(&networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"},
+	})
+
+	p := New(client, Config{IP: "1.2.3.4"})
+	if p.useNetworkingV1 {
+		t.Fatal("useNetworkingV1 = true, want false when discovery has no v1 resources")
+	}
+
+	p.payload = &watcher.Payload{
+		Ingresses: []watcher.IngressPayload{
+			{Ingress: &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}}},
+		},
+	}
+	p.publish(context.Background())
+
+	got, err := client.NetworkingV1beta1().Ingresses("default").Get(context.Background(), "test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(got.Status.LoadBalancer.Ingress) != 1 || got.Status.LoadBalancer.Ingress[0].IP != "1.2.3.4" {
+		t.Errorf("status.loadBalancer.ingress not patched via v1beta1: %+v", got.Status.LoadBalancer)
+	}
+}
+
+func TestPublish_V1(t *testing.T) {
+	client := fake.NewSimpleClientset(&networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"},
+	})
+	fakeDiscovery, ok := client.Discovery().(*fakediscovery.FakeDiscovery)
+	if !ok {
+		t.Fatal("fake clientset discovery isn't a *fakediscovery.FakeDiscovery")
+	}
+	fakeDiscovery.Resources = []*metav1.APIResourceList{
+		{GroupVersion: networkingv1.SchemeGroupVersion.String()},
+	}
+
+	p := New(client, Config{IP: "5.6.7.8"})
+	if !p.useNetworkingV1 {
+		t.Fatal("useNetworkingV1 = false, want true when discovery reports networking.k8s.io/v1")
+	}
+
+	p.payload = &watcher.Payload{
+		Ingresses: []watcher.IngressPayload{
+			{Ingress: &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}}},
+		},
+	}
+	p.publish(context.Background())
+
+	got, err := client.NetworkingV1().Ingresses("default").Get(context.Background(), "test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(got.Status.LoadBalancer.Ingress) != 1 || got.Status.LoadBalancer.Ingress[0].IP != "5.6.7.8" {
+		t.Errorf("status.loadBalancer.ingress not patched via v1: %+v", got.Status.LoadBalancer)
+	}
+}
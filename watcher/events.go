@@ -0,0 +1,52 @@
+package watcher
+
+import "github.com/rs/zerolog/log"
+
+// EventType identifies what kind of change produced an Event.
+type EventType string
+
+const (
+	// IngressAdded is emitted the first time a matching Ingress is seen.
+	IngressAdded EventType = "IngressAdded"
+	// IngressUpdated is emitted when a previously-seen Ingress, or one of
+	// the Services/Secrets it references, changes.
+	IngressUpdated EventType = "IngressUpdated"
+	// IngressDeleted is emitted when a previously-matching Ingress is
+	// removed, or stops matching this controller's class.
+	IngressDeleted EventType = "IngressDeleted"
+)
+
+// An Event describes a single incremental change to the watcher's internal
+// store. Key is the Ingress's "namespace/name".
+type Event struct {
+	Type EventType
+	Key  string
+}
+
+// Events returns the stream of incremental changes to the watcher's
+// Ingress store. Consumers that want the whole picture on every change
+// instead can call Snapshot(), optionally aggregated with the same
+// debounce Run uses internally for its onChange callback.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Snapshot returns the watcher's current view of the cluster as a Payload,
+// built from its incrementally-maintained internal store rather than a
+// full re-list.
+func (w *Watcher) Snapshot() *Payload {
+	payload := w.store.snapshot()
+	payload.IsLeader = w.IsLeader()
+	return payload
+}
+
+// emit pushes ev onto the event channel without blocking the informer
+// goroutine; a slow or absent consumer drops events rather than stalling
+// Kubernetes watches.
+func (w *Watcher) emit(ev Event) {
+	select {
+	case w.events <- ev:
+	default:
+		log.Warn().Str("key", ev.Key).Str("type", string(ev.Type)).Msg("event channel full, dropping event")
+	}
+}
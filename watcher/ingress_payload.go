@@ -0,0 +1,138 @@
+package watcher
+
+import (
+	"crypto/tls"
+
+	"github.com/rs/zerolog/log"
+	networkingv1 "k8s.io/api/networking/v1"
+
+	"github.com/AaronPei/kubernetes-simple-ingress-controller/pkg/annotations"
+)
+
+// serviceResolver resolves a Service's named ports by namespace/name.
+type serviceResolver func(namespace, name string) (map[string]int, error)
+
+// secretResolver resolves a TLS secret into a certificate by namespace/name.
+type secretResolver func(namespace, name string) (*tls.Certificate, error)
+
+// buildIngressPayload translates a single Ingress into its IngressPayload,
+// resolving every Service backend and TLS secret it references. It also
+// returns the "namespace/name" keys of the Services and Secrets referenced -
+// so the store can index them and recompute only the Ingresses a later
+// Service/Secret change actually affects - plus the TLS
+// hosts this Ingress resolves certificates for and the certificates
+// themselves, keyed by host.
+func buildIngressPayload(ingress *networkingv1.Ingress, class string, resolveService serviceResolver, resolveSecret secretResolver) (payload IngressPayload, serviceKeys []string, secretNames []string, hosts []string, certsByHost map[string]*tls.Certificate) {
+	// ingress is the pointer handed back by the informer lister/cache, which
+	// callers must treat as read-only; defaulting PathType below writes back
+	// into it, so work on a copy instead of racing other goroutines (e.g.
+	// resyncAll) reading the same cached object.
+	ingress = ingress.DeepCopy()
+
+	payload = IngressPayload{
+		Ingress:      ingress,
+		ServicePorts: make(map[string]map[string]int),
+		Class:        class,
+		Annotations:  annotations.Parse(ingress.Annotations),
+	}
+	certsByHost = make(map[string]*tls.Certificate)
+
+	addBackend := func(backend networkingv1.IngressBackend) {
+		if backend.Service == nil {
+			// Resource backends (e.g. object storage) aren't ours to proxy.
+			return
+		}
+		serviceKeys = append(serviceKeys, namespacedKey(ingress.Namespace, backend.Service.Name))
+		ports, err := resolveService(ingress.Namespace, backend.Service.Name)
+		if err != nil {
+			log.Error().Err(err).
+				Str("namespace", ingress.Namespace).
+				Str("name", backend.Service.Name).
+				Msg("unknown service")
+			return
+		}
+		payload.ServicePorts[backend.Service.Name] = ports
+	}
+
+	//apiVersion: networking.k8s.io/v1
+	//kind: Ingress
+	//metadata:
+	//  name: test-ingress
+	//spec:
+	//  defaultBackend:
+	//    service:
+	//      name: testsvc
+	//      port:
+	//        number: 80
+	if ingress.Spec.DefaultBackend != nil {
+		addBackend(*ingress.Spec.DefaultBackend)
+	}
+
+	//apiVersion: networking.k8s.io/v1
+	//kind: Ingress
+	//metadata:
+	//  name: test
+	//spec:
+	//  rules:
+	//  - host: foo.bar.com
+	//    http:
+	//      paths:
+	//      - pathType: Prefix
+	//        backend:
+	//          service:
+	//            name: s1
+	//            port:
+	//              number: 80
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for i, path := range rule.HTTP.Paths {
+			// pathType defaults to ImplementationSpecific when unset;
+			// resolve it here so downstream routing never sees nil.
+			if path.PathType == nil {
+				implementationSpecific := networkingv1.PathTypeImplementationSpecific
+				rule.HTTP.Paths[i].PathType = &implementationSpecific
+			}
+			addBackend(path.Backend)
+		}
+	}
+
+	// 证书处理: Payload.TLSCertificates is keyed by SNI hostname rather than
+	// by Secret name, so a TLS handshake can select a certificate by its
+	// server name.
+	var ruleHosts []string
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != "" {
+			ruleHosts = append(ruleHosts, rule.Host)
+		}
+	}
+
+	for _, rec := range ingress.Spec.TLS {
+		if rec.SecretName == "" {
+			continue
+		}
+		secretNames = append(secretNames, namespacedKey(ingress.Namespace, rec.SecretName))
+		cert, err := resolveSecret(ingress.Namespace, rec.SecretName)
+		if err != nil {
+			log.Error().Err(err).
+				Str("namespace", ingress.Namespace).
+				Str("name", rec.SecretName).
+				Msg("unknown or invalid tls secret")
+			continue
+		}
+
+		// rec.Hosts unset means the certificate covers whatever hosts this
+		// Ingress's own rules serve.
+		recHosts := rec.Hosts
+		if len(recHosts) == 0 {
+			recHosts = ruleHosts
+		}
+		for _, host := range recHosts {
+			hosts = append(hosts, host)
+			certsByHost[host] = cert
+		}
+	}
+
+	return payload, serviceKeys, secretNames, hosts, certsByHost
+}
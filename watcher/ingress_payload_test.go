@@ -0,0 +1,114 @@
+package watcher
+
+import (
+	"crypto/tls"
+	"fmt"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestBuildIngressPayload_HTTPRules(t *testing.T) {
+	resolveService := func(namespace, name string) (map[string]int, error) {
+		return map[string]int{"http": 80}, nil
+	}
+	resolveSecret := func(namespace, name string) (*tls.Certificate, error) {
+		return nil, fmt.Errorf("unexpected secret lookup: %s/%s", namespace, name)
+	}
+
+	tests := []struct {
+		name         string
+		ingress      *networkingv1.Ingress
+		wantServices []string
+	}{
+		{
+			// Regression test for a bug where `if rule.HTTP != nil { continue }`
+			// skipped every rule that actually had HTTP paths, leaving
+			// ServicePorts empty for any Ingress using the common
+			// rules-with-host-and-paths shape.
+			name: "rule with HTTP paths is not skipped",
+			ingress: &networkingv1.Ingress{
+				Spec: networkingv1.IngressSpec{
+					Rules: []networkingv1.IngressRule{
+						{
+							Host: "foo.bar.com",
+							IngressRuleValue: networkingv1.IngressRuleValue{
+								HTTP: &networkingv1.HTTPIngressRuleValue{
+									Paths: []networkingv1.HTTPIngressPath{
+										{
+											Backend: networkingv1.IngressBackend{
+												Service: &networkingv1.IngressServiceBackend{
+													Name: "s1",
+													Port: networkingv1.ServiceBackendPort{Number: 80},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantServices: []string{"s1"},
+		},
+		{
+			name: "rule without HTTP is skipped",
+			ingress: &networkingv1.Ingress{
+				Spec: networkingv1.IngressSpec{
+					Rules: []networkingv1.IngressRule{{Host: "foo.bar.com"}},
+				},
+			},
+			wantServices: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, _, _, _, _ := buildIngressPayload(tt.ingress, "simple.io/ingress-controller", resolveService, resolveSecret)
+			if len(payload.ServicePorts) != len(tt.wantServices) {
+				t.Fatalf("ServicePorts = %v, want entries for %v", payload.ServicePorts, tt.wantServices)
+			}
+			for _, name := range tt.wantServices {
+				if _, ok := payload.ServicePorts[name]; !ok {
+					t.Errorf("ServicePorts missing %q: %v", name, payload.ServicePorts)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildIngressPayload_DoesNotMutateSharedIngress(t *testing.T) {
+	resolveService := func(namespace, name string) (map[string]int, error) {
+		return map[string]int{"http": 80}, nil
+	}
+	resolveSecret := func(namespace, name string) (*tls.Certificate, error) {
+		return nil, fmt.Errorf("unexpected secret lookup")
+	}
+
+	original := &networkingv1.Ingress{
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{Name: "s1", Port: networkingv1.ServiceBackendPort{Number: 80}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	_, _, _, _, _ = buildIngressPayload(original, "simple.io/ingress-controller", resolveService, resolveSecret)
+
+	if original.Spec.Rules[0].HTTP.Paths[0].PathType != nil {
+		t.Errorf("buildIngressPayload mutated the caller's Ingress object: PathType = %v, want nil", original.Spec.Rules[0].HTTP.Paths[0].PathType)
+	}
+}
@@ -0,0 +1,197 @@
+package watcher
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/informers"
+	networkingv1listers "k8s.io/client-go/listers/networking/v1"
+	networkingv1beta1listers "k8s.io/client-go/listers/networking/v1beta1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// networkingv1ClassLister is the subset of the generated IngressClass
+// lister this package needs, so the v1beta1 fallback below can satisfy it
+// too.
+type networkingv1ClassLister interface {
+	Get(name string) (*networkingv1.IngressClass, error)
+}
+
+// newIngressLister returns a lister that always yields networking.k8s.io/v1
+// Ingress objects, converting on the fly from v1beta1 when useV1 is false.
+func newIngressLister(factory informers.SharedInformerFactory, useV1 bool) ingressLister {
+	if useV1 {
+		return v1IngressLister{lister: factory.Networking().V1().Ingresses().Lister()}
+	}
+	return v1beta1IngressLister{lister: factory.Networking().V1beta1().Ingresses().Lister()}
+}
+
+// newIngressClassLister returns a lister for IngressClass objects: the v1
+// lister when useV1 is set, otherwise the v1beta1 one. It never returns nil;
+// a cluster that serves neither version is not detected here.
+func newIngressClassLister(factory informers.SharedInformerFactory, useV1 bool) networkingv1ClassLister {
+	if useV1 {
+		return factory.Networking().V1().IngressClasses().Lister()
+	}
+	return v1beta1ClassLister{lister: factory.Networking().V1beta1().IngressClasses().Lister()}
+}
+
+// ingressInformer returns the shared informer for the Ingress resource
+// version this cluster serves.
+func ingressInformer(factory informers.SharedInformerFactory, useV1 bool) cache.SharedIndexInformer {
+	if useV1 {
+		return factory.Networking().V1().Ingresses().Informer()
+	}
+	return factory.Networking().V1beta1().Ingresses().Informer()
+}
+
+// ingressClassInformer returns the shared informer for IngressClass: v1 when
+// useV1 is set, otherwise v1beta1. It never returns nil; a cluster that
+// predates the resource entirely (pre-1.18) is not detected here.
+func ingressClassInformer(factory informers.SharedInformerFactory, useV1 bool) cache.SharedIndexInformer {
+	if useV1 {
+		return factory.Networking().V1().IngressClasses().Informer()
+	}
+	return factory.Networking().V1beta1().IngressClasses().Informer()
+}
+
+// ingressLister is the subset of the generated v1 Ingress lister this
+// package needs.
+type ingressLister interface {
+	List(selector labels.Selector) ([]*networkingv1.Ingress, error)
+	Get(namespace, name string) (*networkingv1.Ingress, error)
+}
+
+// v1IngressLister is the passthrough case: the cluster already serves
+// networking.k8s.io/v1.
+type v1IngressLister struct {
+	lister networkingv1listers.IngressLister
+}
+
+func (l v1IngressLister) List(selector labels.Selector) ([]*networkingv1.Ingress, error) {
+	return l.lister.List(selector)
+}
+
+func (l v1IngressLister) Get(namespace, name string) (*networkingv1.Ingress, error) {
+	return l.lister.Ingresses(namespace).Get(name)
+}
+
+// v1beta1IngressLister adapts the deprecated networking.k8s.io/v1beta1
+// Ingress lister to the v1 shape by converting every object on List.
+type v1beta1IngressLister struct {
+	lister interface {
+		List(selector labels.Selector) ([]*networkingv1beta1.Ingress, error)
+		Ingresses(namespace string) networkingv1beta1listers.IngressNamespaceLister
+	}
+}
+
+func (l v1beta1IngressLister) List(selector labels.Selector) ([]*networkingv1.Ingress, error) {
+	old, err := l.lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]*networkingv1.Ingress, 0, len(old))
+	for _, ing := range old {
+		converted = append(converted, convertIngressV1beta1ToV1(ing))
+	}
+	return converted, nil
+}
+
+func (l v1beta1IngressLister) Get(namespace, name string) (*networkingv1.Ingress, error) {
+	old, err := l.lister.Ingresses(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return convertIngressV1beta1ToV1(old), nil
+}
+
+// v1beta1ClassLister adapts the deprecated networking.k8s.io/v1beta1
+// IngressClass lister to the v1 shape.
+type v1beta1ClassLister struct {
+	lister interface {
+		Get(name string) (*networkingv1beta1.IngressClass, error)
+	}
+}
+
+func (l v1beta1ClassLister) Get(name string) (*networkingv1.IngressClass, error) {
+	old, err := l.lister.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	// Parameters isn't used anywhere downstream (only Controller drives
+	// matchClass), and is a distinct generated type between API versions, so
+	// it's dropped here rather than translated field-by-field.
+	return &networkingv1.IngressClass{
+		ObjectMeta: old.ObjectMeta,
+		Spec: networkingv1.IngressClassSpec{
+			Controller: old.Spec.Controller,
+		},
+	}, nil
+}
+
+// convertIngressV1beta1ToV1 translates a deprecated networking.k8s.io/v1beta1
+// Ingress into its networking.k8s.io/v1 shape, so the rest of the watcher
+// only ever has to deal with one representation.
+func convertIngressV1beta1ToV1(in *networkingv1beta1.Ingress) *networkingv1.Ingress {
+	out := &networkingv1.Ingress{
+		ObjectMeta: in.ObjectMeta,
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: in.Spec.IngressClassName,
+		},
+	}
+
+	if in.Spec.Backend != nil {
+		out.Spec.DefaultBackend = convertBackendV1beta1ToV1(*in.Spec.Backend)
+	}
+
+	for _, rule := range in.Spec.Rules {
+		v1Rule := networkingv1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			v1Rule.HTTP = &networkingv1.HTTPIngressRuleValue{}
+			for _, path := range rule.HTTP.Paths {
+				var pathType *networkingv1.PathType
+				if path.PathType != nil {
+					pt := networkingv1.PathType(*path.PathType)
+					pathType = &pt
+				}
+				v1Rule.HTTP.Paths = append(v1Rule.HTTP.Paths, networkingv1.HTTPIngressPath{
+					Path:     path.Path,
+					PathType: pathType,
+					Backend:  *convertBackendV1beta1ToV1(path.Backend),
+				})
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, v1Rule)
+	}
+
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, networkingv1.IngressTLS{
+			Hosts:      tls.Hosts,
+			SecretName: tls.SecretName,
+		})
+	}
+
+	return out
+}
+
+// convertBackendV1beta1ToV1 translates the v1beta1 ServiceName/ServicePort
+// pair into the v1 IngressServiceBackend shape.
+func convertBackendV1beta1ToV1(in networkingv1beta1.IngressBackend) *networkingv1.IngressBackend {
+	if in.ServiceName == "" {
+		return &networkingv1.IngressBackend{Resource: in.Resource}
+	}
+	port := networkingv1.ServiceBackendPort{}
+	if in.ServicePort.Type == intstr.String {
+		port.Name = in.ServicePort.StrVal
+	} else {
+		port.Number = in.ServicePort.IntVal
+	}
+	return &networkingv1.IngressBackend{
+		Service: &networkingv1.IngressServiceBackend{
+			Name: in.ServiceName,
+			Port: port,
+		},
+		Resource: in.Resource,
+	}
+}
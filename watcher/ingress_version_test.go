@@ -0,0 +1,127 @@
+package watcher
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestConvertBackendV1beta1ToV1(t *testing.T) {
+	tests := []struct {
+		name string
+		in   networkingv1beta1.IngressBackend
+		want *networkingv1.IngressBackend
+	}{
+		{
+			name: "named port",
+			in:   networkingv1beta1.IngressBackend{ServiceName: "s1", ServicePort: intstr.FromString("http")},
+			want: &networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{Name: "s1", Port: networkingv1.ServiceBackendPort{Name: "http"}},
+			},
+		},
+		{
+			name: "numeric port",
+			in:   networkingv1beta1.IngressBackend{ServiceName: "s1", ServicePort: intstr.FromInt(80)},
+			want: &networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{Name: "s1", Port: networkingv1.ServiceBackendPort{Number: 80}},
+			},
+		},
+		{
+			name: "resource backend has no service name",
+			in:   networkingv1beta1.IngressBackend{Resource: &corev1TypedLocalObjectReference},
+			want: &networkingv1.IngressBackend{Resource: &corev1TypedLocalObjectReference},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertBackendV1beta1ToV1(tt.in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertBackendV1beta1ToV1(%+v) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertIngressV1beta1ToV1(t *testing.T) {
+	pathType := networkingv1beta1.PathTypePrefix
+	in := &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{
+				{
+					Host: "foo.bar.com",
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{
+						HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+							Paths: []networkingv1beta1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: &pathType,
+									Backend:  networkingv1beta1.IngressBackend{ServiceName: "s1", ServicePort: intstr.FromInt(80)},
+								},
+							},
+						},
+					},
+				},
+			},
+			TLS: []networkingv1beta1.IngressTLS{
+				{Hosts: []string{"foo.bar.com"}, SecretName: "foo-tls"},
+			},
+		},
+	}
+
+	out := convertIngressV1beta1ToV1(in)
+
+	if out.Namespace != "default" || out.Name != "test" {
+		t.Fatalf("ObjectMeta not preserved: %+v", out.ObjectMeta)
+	}
+	if len(out.Spec.Rules) != 1 || out.Spec.Rules[0].Host != "foo.bar.com" {
+		t.Fatalf("rules not converted: %+v", out.Spec.Rules)
+	}
+	paths := out.Spec.Rules[0].HTTP.Paths
+	if len(paths) != 1 || paths[0].Backend.Service == nil || paths[0].Backend.Service.Name != "s1" || paths[0].Backend.Service.Port.Number != 80 {
+		t.Fatalf("backend not converted: %+v", paths)
+	}
+	if *paths[0].PathType != networkingv1.PathTypePrefix {
+		t.Errorf("PathType = %v, want Prefix", *paths[0].PathType)
+	}
+	if len(out.Spec.TLS) != 1 || out.Spec.TLS[0].SecretName != "foo-tls" {
+		t.Fatalf("TLS not converted: %+v", out.Spec.TLS)
+	}
+}
+
+func TestV1beta1ClassLister_Get(t *testing.T) {
+	inner := fakeV1beta1ClassGetter{
+		class: &networkingv1beta1.IngressClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "simple"},
+			Spec: networkingv1beta1.IngressClassSpec{
+				Controller: "simple.io/ingress-controller",
+				Parameters: &networkingv1beta1.IngressClassParametersReference{Kind: "ConfigMap", Name: "params"},
+			},
+		},
+	}
+	lister := v1beta1ClassLister{lister: inner}
+
+	got, err := lister.Get("simple")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if got.Spec.Controller != "simple.io/ingress-controller" {
+		t.Errorf("Controller = %q, want simple.io/ingress-controller", got.Spec.Controller)
+	}
+}
+
+type fakeV1beta1ClassGetter struct {
+	class *networkingv1beta1.IngressClass
+}
+
+func (f fakeV1beta1ClassGetter) Get(name string) (*networkingv1beta1.IngressClass, error) {
+	return f.class, nil
+}
+
+var corev1TypedLocalObjectReference = corev1.TypedLocalObjectReference{Kind: "StorageBucket", Name: "assets"}
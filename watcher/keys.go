@@ -0,0 +1,42 @@
+package watcher
+
+import (
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// splitNamespacedKey reverses namespacedKey.
+func splitNamespacedKey(key string) (namespace, name string, ok bool) {
+	ns, n, found := strings.Cut(key, "/")
+	if !found {
+		return "", "", false
+	}
+	return ns, n, true
+}
+
+// keyOf extracts the "namespace/name" key from an informer event object,
+// unwrapping cache.DeletedFinalStateUnknown for delete events.
+func keyOf(obj interface{}) (string, bool) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// asIngress type-asserts an informer event object to the Ingress version
+// the cluster serves, normalizing v1beta1 to the v1 shape.
+func asIngress(obj interface{}, useV1 bool) *networkingv1.Ingress {
+	if useV1 {
+		ingress, _ := obj.(*networkingv1.Ingress)
+		return ingress
+	}
+	ingress, ok := obj.(*networkingv1beta1.Ingress)
+	if !ok {
+		return nil
+	}
+	return convertIngressV1beta1ToV1(ingress)
+}
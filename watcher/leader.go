@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeaderElectionConfig configures the Lease resource multiple controller
+// replicas coordinate on. Only one replica at a time is leader; see
+// Watcher.IsLeader.
+type LeaderElectionConfig struct {
+	// LeaseName/LeaseNamespace identify the Lease object replicas
+	// coordinate on. Required.
+	LeaseName      string
+	LeaseNamespace string
+
+	// Identity uniquely identifies this replica in the Lease. Defaults to
+	// the pod hostname.
+	Identity string
+
+	// LeaseDuration/RenewDeadline/RetryPeriod tune how quickly a dead
+	// leader is detected and replaced. Zero values fall back to
+	// client-go's usual leader-election defaults.
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+func (c LeaderElectionConfig) identity() string {
+	if c.Identity != "" {
+		return c.Identity
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
+
+func (c LeaderElectionConfig) leaseDuration() time.Duration {
+	if c.LeaseDuration > 0 {
+		return c.LeaseDuration
+	}
+	return 15 * time.Second
+}
+
+func (c LeaderElectionConfig) renewDeadline() time.Duration {
+	if c.RenewDeadline > 0 {
+		return c.RenewDeadline
+	}
+	return 10 * time.Second
+}
+
+func (c LeaderElectionConfig) retryPeriod() time.Duration {
+	if c.RetryPeriod > 0 {
+		return c.RetryPeriod
+	}
+	return 2 * time.Second
+}
+
+// WithLeaderElection enables leader election for HA deployments: every
+// replica keeps running its watchers and serving traffic, but only the
+// elected leader should be treated as authoritative for write-side work
+// (Ingress status patching, ACME/certificate acquisition, metrics scraping
+// of upstreams). Watcher.IsLeader reports the current state, and every
+// Payload handed to onChange carries it as Payload.IsLeader.
+func WithLeaderElection(cfg LeaderElectionConfig) Option {
+	return func(w *Watcher) {
+		w.leaderElectionCfg = &cfg
+	}
+}
+
+// IsLeader reports whether this replica currently holds leadership. When
+// leader election isn't configured, every replica is always the leader.
+func (w *Watcher) IsLeader() bool {
+	return w.leading.Load()
+}
+
+// runLeaderElection blocks participating in leader election until ctx is
+// cancelled, flipping w.leading on every state transition and calling
+// onTransition so a consumer already subscribed to onChange observes the
+// new Payload.IsLeader value immediately, instead of waiting for the next
+// unrelated Ingress/Service/Secret event (or never, on a quiet cluster).
+func (w *Watcher) runLeaderElection(ctx context.Context, onTransition func()) {
+	cfg := w.leaderElectionCfg
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      cfg.LeaseName,
+			Namespace: cfg.LeaseNamespace,
+		},
+		Client: w.client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: cfg.identity(),
+		},
+	}
+
+	electionCfg := leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: cfg.leaseDuration(),
+		RenewDeadline: cfg.renewDeadline(),
+		RetryPeriod:   cfg.retryPeriod(),
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Info().Str("identity", cfg.identity()).Msg("acquired leadership")
+				w.leading.Store(true)
+				onTransition()
+			},
+			OnStoppedLeading: func() {
+				log.Info().Str("identity", cfg.identity()).Msg("lost leadership")
+				w.leading.Store(false)
+				onTransition()
+			},
+			OnNewLeader: func(identity string) {
+				log.Info().Str("leader", identity).Msg("observed new leader")
+			},
+		},
+	}
+
+	// RunOrDie returns once leadership is lost, so campaign again until ctx
+	// is cancelled.
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, electionCfg)
+	}
+}
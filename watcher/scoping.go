@@ -0,0 +1,101 @@
+package watcher
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Option configures optional Watcher behaviour.
+type Option func(*Watcher)
+
+// WithNamespaces restricts the watcher's informers to the given namespaces,
+// instead of watching the whole cluster. Mirrors Traefik's Namespaces
+// option; useful for multi-tenant deployments where several controller
+// instances are sharded by namespace.
+func WithNamespaces(namespaces ...string) Option {
+	return func(w *Watcher) {
+		w.namespaces = namespaces
+	}
+}
+
+// WithLabelSelector restricts the watcher's informers to Ingresses,
+// Secrets and Services matching selector. Useful for sharding several
+// controller instances by label instead of (or in addition to) namespace.
+func WithLabelSelector(selector labels.Selector) Option {
+	return func(w *Watcher) {
+		w.labelSelector = selector
+	}
+}
+
+// namespaceScope bundles the listers and informers built from a single
+// SharedInformerFactory, which may be restricted to one namespace.
+type namespaceScope struct {
+	namespace string // "" means cluster-wide
+	factory   informers.SharedInformerFactory
+	secrets   corelisters.SecretLister
+	services  corelisters.ServiceLister
+	ingresses ingressLister
+}
+
+// buildScopes constructs one namespaceScope per configured namespace (or a
+// single cluster-wide scope when none are configured), each backed by its
+// own SharedInformerFactory so informers only watch their namespace. It
+// also returns a router from namespace name to the scope that owns it, used
+// to look up Services/Secrets by the namespace of the Ingress that
+// references them, plus a separate cluster-wide factory with no label
+// selector applied, for cluster-scoped resources (IngressClass) that
+// WithLabelSelector was never meant to filter - IngressClass objects don't
+// carry the sharding label an Ingress/Secret/Service would, so tweaking
+// their list/watch the same way would make every IngressClass invisible.
+func (w *Watcher) buildScopes(client kubernetes.Interface) ([]*namespaceScope, map[string]*namespaceScope, informers.SharedInformerFactory) {
+	var tweak informers.SharedInformerOption
+	if w.labelSelector != nil && !w.labelSelector.Empty() {
+		selector := w.labelSelector.String()
+		tweak = informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = selector
+		})
+	}
+
+	newFactory := func(namespace string) informers.SharedInformerFactory {
+		opts := []informers.SharedInformerOption{informers.WithNamespace(namespace)}
+		if tweak != nil {
+			opts = append(opts, tweak)
+		}
+		return informers.NewSharedInformerFactoryWithOptions(client, time.Minute, opts...)
+	}
+
+	classFactory := informers.NewSharedInformerFactory(client, time.Minute)
+
+	if len(w.namespaces) == 0 {
+		factory := newFactory(metav1.NamespaceAll)
+		scope := &namespaceScope{
+			factory:   factory,
+			secrets:   factory.Core().V1().Secrets().Lister(),
+			services:  factory.Core().V1().Services().Lister(),
+			ingresses: newIngressLister(factory, w.useNetworkingV1),
+		}
+		return []*namespaceScope{scope}, map[string]*namespaceScope{"": scope}, classFactory
+	}
+
+	scopes := make([]*namespaceScope, 0, len(w.namespaces))
+	router := make(map[string]*namespaceScope, len(w.namespaces))
+	for _, ns := range w.namespaces {
+		factory := newFactory(ns)
+		scope := &namespaceScope{
+			namespace: ns,
+			factory:   factory,
+			secrets:   factory.Core().V1().Secrets().Lister(),
+			services:  factory.Core().V1().Services().Lister(),
+			ingresses: newIngressLister(factory, w.useNetworkingV1),
+		}
+		scopes = append(scopes, scope)
+		router[ns] = scope
+	}
+	return scopes, router, classFactory
+}
@@ -0,0 +1,202 @@
+package watcher
+
+import (
+	"crypto/tls"
+	"sync"
+)
+
+// namespacedKey builds the "namespace/name" key used throughout the store.
+func namespacedKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// refIndex tracks, for a set of owners (Ingress keys), which reference keys
+// (Service keys, Secret names, TLS hosts, ...) each one currently depends
+// on, plus the reverse mapping used to answer "who depends on ref X".
+type refIndex struct {
+	owners  map[string][]string            // owner -> refs
+	reverse map[string]map[string]struct{} // ref -> owners
+}
+
+func newRefIndex() *refIndex {
+	return &refIndex{
+		owners:  make(map[string][]string),
+		reverse: make(map[string]map[string]struct{}),
+	}
+}
+
+func (idx *refIndex) ownersOf(ref string) []string {
+	return keysOf(idx.reverse[ref])
+}
+
+// update replaces owner's refs, returning every old ref of owner that had no
+// other owner - so the caller can evict data keyed by that ref (e.g. a
+// cached certificate no Ingress references anymore). Such a ref is returned
+// even when refs lists it again; the caller re-adds whatever is still current.
+func (idx *refIndex) update(owner string, refs []string) (emptied []string) {
+	for _, old := range idx.owners[owner] {
+		delete(idx.reverse[old], owner)
+		if len(idx.reverse[old]) == 0 {
+			delete(idx.reverse, old)
+			emptied = append(emptied, old)
+		}
+	}
+	if len(refs) == 0 {
+		delete(idx.owners, owner)
+		return emptied
+	}
+	idx.owners[owner] = refs
+	for _, ref := range refs {
+		if idx.reverse[ref] == nil {
+			idx.reverse[ref] = make(map[string]struct{})
+		}
+		idx.reverse[ref][owner] = struct{}{}
+	}
+	return emptied
+}
+
+func keysOf(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// ingressStore is the watcher's incrementally-updated view of the cluster.
+// Every Ingress, Service and Secret event updates only the entries it
+// actually affects, instead of the old full re-list-and-rebuild on every
+// change.
+type ingressStore struct {
+	mu sync.RWMutex
+
+	ingresses map[string]IngressPayload // key: ingress "namespace/name"
+
+	// certsByHost holds TLS certificates keyed by the SNI hostname they
+	// serve, as found in Ingress.Spec.TLS[].Hosts (or the Ingress's rule
+	// hosts when a TLS entry lists none). Several Ingresses listing the same
+	// host share one entry, holding the certificate of whichever of them was
+	// upserted last.
+	certsByHost map[string]*tls.Certificate
+
+	// defaultCert, if set via WithDefaultTLSSecret, is served when
+	// a TLS handshake's SNI doesn't match any entry in certsByHost.
+	defaultCert *tls.Certificate
+
+	// defaultBackend, if set via WithDefaultBackend, is the
+	// controller-wide fallback used when no Ingress host/path rule
+	// matches a request.
+	defaultBackend *Backend
+
+	// Reverse indices, so a Service/Secret event only recomputes the
+	// Ingresses that actually reference it, and so a cert can be evicted
+	// once no Ingress references its host anymore.
+	serviceRefs *refIndex // service key -> ingress keys
+	secretRefs  *refIndex // secret key ("namespace/name") -> ingress keys (recompute only)
+	hostRefs    *refIndex // TLS host -> ingress keys (drives certsByHost)
+}
+
+func newIngressStore() *ingressStore {
+	return &ingressStore{
+		ingresses:   make(map[string]IngressPayload),
+		certsByHost: make(map[string]*tls.Certificate),
+		serviceRefs: newRefIndex(),
+		secretRefs:  newRefIndex(),
+		hostRefs:    newRefIndex(),
+	}
+}
+
+// snapshot builds a full Payload from the current store contents.
+func (s *ingressStore) snapshot() *Payload {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	payload := &Payload{
+		TLSCertificates: make(map[string]*tls.Certificate, len(s.certsByHost)),
+		DefaultCert:     s.defaultCert,
+		DefaultBackend:  s.defaultBackend,
+	}
+	for _, ingressPayload := range s.ingresses {
+		payload.Ingresses = append(payload.Ingresses, ingressPayload)
+	}
+	for host, cert := range s.certsByHost {
+		payload.TLSCertificates[host] = cert
+	}
+	return payload
+}
+
+// upsert records payload under key, refreshing the Service/Secret/host
+// reverse indices so future events know to recompute or evict it. It
+// reports whether key is new to the store.
+func (s *ingressStore) upsert(key string, payload IngressPayload, serviceKeys, secretNames, hosts []string, certsByHost map[string]*tls.Certificate) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, existed := s.ingresses[key]
+	s.ingresses[key] = payload
+
+	s.serviceRefs.update(key, serviceKeys)
+	s.secretRefs.update(key, secretNames)
+	for _, emptied := range s.hostRefs.update(key, hosts) {
+		delete(s.certsByHost, emptied)
+	}
+	for host, cert := range certsByHost {
+		s.certsByHost[host] = cert
+	}
+
+	return !existed
+}
+
+// remove drops key from the store, cleaning up any Service/Secret/host
+// index entries and TLS certificates that were only referenced by it. It
+// reports whether key was present.
+func (s *ingressStore) remove(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, existed := s.ingresses[key]
+	if !existed {
+		return false
+	}
+	delete(s.ingresses, key)
+	s.serviceRefs.update(key, nil)
+	s.secretRefs.update(key, nil)
+	for _, emptied := range s.hostRefs.update(key, nil) {
+		delete(s.certsByHost, emptied)
+	}
+	return true
+}
+
+// ingressKeysForService returns the Ingresses currently referencing the
+// Service identified by serviceKey ("namespace/name").
+func (s *ingressStore) ingressKeysForService(serviceKey string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.serviceRefs.ownersOf(serviceKey)
+}
+
+// ingressKeysForSecret returns the Ingresses currently referencing the
+// Secret identified by secretKey ("namespace/name"). Keying by the full
+// namespaced key, the same way ingressKeysForService does, keeps two
+// same-named Secrets in different namespaces (common for shared TLS certs)
+// from being conflated.
+func (s *ingressStore) ingressKeysForSecret(secretKey string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.secretRefs.ownersOf(secretKey)
+}
+
+// setDefaultCert replaces the fallback certificate served when SNI doesn't
+// match any Ingress-provided host.
+func (s *ingressStore) setDefaultCert(cert *tls.Certificate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultCert = cert
+}
+
+// setDefaultBackend replaces the controller-wide fallback backend.
+func (s *ingressStore) setDefaultBackend(backend *Backend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultBackend = backend
+}
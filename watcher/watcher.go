@@ -3,195 +3,485 @@ package watcher
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bep/debounce"
 	"github.com/rs/zerolog/log"
-	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+
+	networkingv1 "k8s.io/api/networking/v1"
+
+	"github.com/AaronPei/kubernetes-simple-ingress-controller/pkg/annotations"
 )
 
+// defaultControllerClass is the IngressClass controller name this
+// controller answers to when none is configured.
+const defaultControllerClass = "simple.io/ingress-controller"
+
+// legacyIngressClassAnnotation is the deprecated way of selecting a
+// controller, kept around for clusters/manifests that predate IngressClass.
+const legacyIngressClassAnnotation = "kubernetes.io/ingress.class"
+
 // A Payload is a collection of Kubernetes data loaded by the watcher.
 type Payload struct {
-	Ingresses       []IngressPayload
+	Ingresses []IngressPayload
+	// TLSCertificates is keyed by SNI hostname (Ingress.Spec.TLS[].Hosts),
+	// not by Secret name. When several Ingresses list the same host, the
+	// entry holds the certificate of whichever of them was processed last.
 	TLSCertificates map[string]*tls.Certificate
+	// DefaultCert, set via WithDefaultTLSSecret, is served when a
+	// TLS handshake's SNI doesn't match any entry in TLSCertificates.
+	DefaultCert *tls.Certificate
+	// DefaultBackend, set via WithDefaultBackend, is the
+	// controller-wide fallback used when no Ingress host/path rule
+	// matches a request, mirroring nginx-ingress's
+	// --default-backend-service flag.
+	DefaultBackend *Backend
+	// IsLeader reports whether this replica held leadership at the time
+	// this Payload was built. Always true when WithLeaderElection
+	// wasn't used. Consumers doing write-side work (status patching,
+	// ACME, metrics scraping) should check this and stay passive when
+	// false.
+	IsLeader bool
+}
+
+// A Backend is a Service and its named ports, detached from any particular
+// Ingress - used for the controller-wide default backend.
+type Backend struct {
+	ServiceName  string
+	ServicePorts map[string]int
+}
+
+// GetCertificate implements the tls.Config.GetCertificate signature, so a
+// Payload can be wired straight into an HTTPS server: it selects the
+// certificate matching the handshake's SNI server name, falling back to
+// DefaultCert when nothing matches.
+func (p *Payload) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert, ok := p.TLSCertificates[hello.ServerName]; ok {
+		return cert, nil
+	}
+	if p.DefaultCert != nil {
+		return p.DefaultCert, nil
+	}
+	return nil, fmt.Errorf("no certificate found for server name %q", hello.ServerName)
 }
 
 // An IngressPayload is an ingress + its service ports.
 type IngressPayload struct {
-	Ingress      *extensionsv1beta1.Ingress
+	Ingress      *networkingv1.Ingress
 	ServicePorts map[string]map[string]int
+	// Class is the IngressClass name (or legacy kubernetes.io/ingress.class
+	// value) that matched this controller for this ingress.
+	Class string
+	// Annotations holds the per-Ingress settings parsed from
+	// metadata.annotations, so the router/proxy layer can apply them
+	// without re-reading annotations itself.
+	Annotations annotations.Config
 }
 
 // A Watcher watches for ingresses in the kubernetes cluster
 type Watcher struct {
 	client   kubernetes.Interface
 	onChange func(*Payload)
+
+	// controllerClass is matched against IngressClass.Spec.Controller.
+	controllerClass string
+	// legacyClass is matched against the legacy kubernetes.io/ingress.class
+	// annotation for ingresses that don't set spec.ingressClassName.
+	legacyClass string
+
+	// useNetworkingV1 records whether the cluster serves
+	// networking.k8s.io/v1, detected once in Run via the discovery API.
+	useNetworkingV1 bool
+
+	// namespaces restricts informers to these namespaces when non-empty.
+	// See WithNamespaces.
+	namespaces []string
+	// labelSelector restricts informers to matching objects. See
+	// WithLabelSelector.
+	labelSelector labels.Selector
+
+	store  *ingressStore
+	events chan Event
+
+	// defaultBackendKey is the "namespace/name" of the Service used as the
+	// controller-wide fallback backend. See WithDefaultBackend.
+	defaultBackendKey string
+	// defaultTLSSecretKey is the "namespace/name" of the Secret used as the
+	// fallback TLS certificate. See WithDefaultTLSSecret.
+	defaultTLSSecretKey string
+
+	// leaderElectionCfg enables leader election when set. See
+	// WithLeaderElection.
+	leaderElectionCfg *LeaderElectionConfig
+	// leading reports whether this replica currently holds leadership.
+	// Always true when leader election isn't configured. See IsLeader.
+	leading atomic.Bool
+}
+
+// WithDefaultBackend sets a controller-wide fallback Service (given as
+// "namespace/name") used when no Ingress host/path rule matches a request,
+// mirroring nginx-ingress's --default-backend-service flag.
+func WithDefaultBackend(serviceKey string) Option {
+	return func(w *Watcher) {
+		w.defaultBackendKey = serviceKey
+	}
+}
+
+// WithDefaultTLSSecret sets a fallback TLS Secret (given as
+// "namespace/name") served when a handshake's SNI doesn't match any
+// Ingress-provided host.
+func WithDefaultTLSSecret(secretKey string) Option {
+	return func(w *Watcher) {
+		w.defaultTLSSecretKey = secretKey
+	}
+}
+
+// New creates a new Watcher. controllerClass is matched against
+// IngressClass.Spec.Controller; legacyClass is matched against the legacy
+// kubernetes.io/ingress.class annotation. Both default when empty so
+// multiple controllers can coexist in one cluster.
+func New(client kubernetes.Interface, controllerClass, legacyClass string, onChange func(*Payload), opts ...Option) *Watcher {
+	if controllerClass == "" {
+		controllerClass = defaultControllerClass
+	}
+	if legacyClass == "" {
+		legacyClass = controllerClass
+	}
+	w := &Watcher{
+		client:          client,
+		onChange:        onChange,
+		controllerClass: controllerClass,
+		legacyClass:     legacyClass,
+		useNetworkingV1: true,
+		store:           newIngressStore(),
+		events:          make(chan Event, 256),
+	}
+	w.leading.Store(true)
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// detectNetworkingAPI figures out whether the cluster serves
+// networking.k8s.io/v1, falling back to the v1beta1 Ingress API (removed in
+// Kubernetes 1.22) for older clusters.
+func (w *Watcher) detectNetworkingAPI() {
+	_, err := w.client.Discovery().ServerResourcesForGroupVersion(networkingv1.SchemeGroupVersion.String())
+	if err != nil {
+		log.Warn().Err(err).Msg("networking.k8s.io/v1 not available on this cluster, falling back to v1beta1 Ingresses")
+		w.useNetworkingV1 = false
+		return
+	}
+	w.useNetworkingV1 = true
 }
 
-// New creates a new Watcher.
-func New(client kubernetes.Interface, onChange func(*Payload)) *Watcher {
-	return &Watcher{
-		client:   client,
-		onChange: onChange,
+// matchClass reports whether ingress is owned by this controller and, if so,
+// the IngressClass name (or legacy annotation value) that matched.
+func (w *Watcher) matchClass(ingress *networkingv1.Ingress, classLister networkingv1ClassLister) (string, bool) {
+	if ingress.Spec.IngressClassName != nil {
+		name := *ingress.Spec.IngressClassName
+		if classLister == nil {
+			return name, false
+		}
+		class, err := classLister.Get(name)
+		if err != nil {
+			log.Error().Err(err).Str("ingressClass", name).Msg("unknown ingress class")
+			return name, false
+		}
+		return name, class.Spec.Controller == w.controllerClass
+	}
+
+	if legacy, ok := ingress.Annotations[legacyIngressClassAnnotation]; ok {
+		return legacy, legacy == w.legacyClass
 	}
+
+	// Neither spec.ingressClassName nor the legacy annotation is set; this
+	// ingress isn't explicitly targeted at any controller.
+	return "", false
 }
 
 // Run runs the watcher.
 func (w *Watcher) Run(ctx context.Context) error {
-	factory := informers.NewSharedInformerFactory(w.client, time.Minute)
-	secretLister := factory.Core().V1().Secrets().Lister()
-	serviceLister := factory.Core().V1().Services().Lister()
-	ingressLister := factory.Extensions().V1beta1().Ingresses().Lister()
+	w.detectNetworkingAPI()
 
-	addBackend := func(ingressPayload *IngressPayload, backend extensionsv1beta1.IngressBackend) {
+	scopes, router, classFactory := w.buildScopes(w.client)
+
+	// IngressClass is cluster-scoped, and - unlike Ingresses/Secrets/Services
+	// - isn't filtered by WithLabelSelector, so it's served from its own
+	// untweaked factory rather than any namespaceScope's.
+	classLister := newIngressClassLister(classFactory, w.useNetworkingV1)
+
+	// scopeForNamespace routes a Service/Secret lookup to the scope that
+	// owns ns. When the watcher isn't namespace-restricted there's a
+	// single, cluster-wide scope for every namespace.
+	scopeForNamespace := func(ns string) *namespaceScope {
+		if scope, ok := router[ns]; ok {
+			return scope
+		}
+		return router[""]
+	}
+
+	resolveService := func(namespace, name string) (map[string]int, error) {
 		// 通过 Ingress 所在的 namespace 和 ServiceName 获取 Service 对象
-		svc, err := serviceLister.Services(ingressPayload.Ingress.Namespace).Get(backend.ServiceName)
+		svc, err := scopeForNamespace(namespace).services.Services(namespace).Get(name)
 		if err != nil {
-			log.Error().Err(err).
-				Str("namespace", ingressPayload.Ingress.Namespace).
-				Str("name", backend.ServiceName).
-				Msg("unknown service")
-		} else {
-			// Service 端口映射
-			m := make(map[string]int)
-			for _, port := range svc.Spec.Ports {
-				m[port.Name] = int(port.Port)
+			return nil, err
+		}
+		// Service 端口映射: {httpport: 80, httpsport: 443}
+		ports := make(map[string]int, len(svc.Spec.Ports))
+		for _, port := range svc.Spec.Ports {
+			ports[port.Name] = int(port.Port)
+		}
+		return ports, nil
+	}
+
+	resolveSecret := func(namespace, name string) (*tls.Certificate, error) {
+		secret, err := scopeForNamespace(namespace).secrets.Secrets(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		// 加载证书
+		cert, err := tls.X509KeyPair(secret.Data["tls.crt"], secret.Data["tls.key"])
+		if err != nil {
+			return nil, err
+		}
+		return &cert, nil
+	}
+
+	// recomputeIngress rebuilds a single Ingress's entry in the store and
+	// emits the Event that resulted. An Ingress that still doesn't match our
+	// class emits nothing; a matching one already in the store always emits
+	// IngressUpdated, even when its payload is unchanged.
+	recomputeIngress := func(ingress *networkingv1.Ingress) {
+		key := namespacedKey(ingress.Namespace, ingress.Name)
+
+		class, ok := w.matchClass(ingress, classLister)
+		if !ok {
+			if w.store.remove(key) {
+				w.emit(Event{Type: IngressDeleted, Key: key})
 			}
-			ingressPayload.ServicePorts[svc.Name] = m
-			// {svcname: {httpport: 80, httpsport: 443}}
+			return
+		}
+
+		payload, serviceKeys, secretNames, hosts, certsByHost := buildIngressPayload(ingress, class, resolveService, resolveSecret)
+		isNew := w.store.upsert(key, payload, serviceKeys, secretNames, hosts, certsByHost)
+		if isNew {
+			w.emit(Event{Type: IngressAdded, Key: key})
+		} else {
+			w.emit(Event{Type: IngressUpdated, Key: key})
 		}
 	}
 
-	onChange := func() {
-		payload := &Payload{
-			TLSCertificates: make(map[string]*tls.Certificate),
+	resolveDefaultBackend := func() {
+		if w.defaultBackendKey == "" {
+			return
+		}
+		namespace, name, ok := splitNamespacedKey(w.defaultBackendKey)
+		if !ok {
+			log.Error().Str("defaultBackend", w.defaultBackendKey).Msg("invalid default backend, want namespace/name")
+			return
+		}
+		ports, err := resolveService(namespace, name)
+		if err != nil {
+			log.Error().Err(err).Str("defaultBackend", w.defaultBackendKey).Msg("unknown default backend service")
+			return
 		}
+		w.store.setDefaultBackend(&Backend{ServiceName: name, ServicePorts: ports})
+	}
 
-		// 获得所有的 Ingress
-		ingresses, err := ingressLister.List(labels.Everything())
+	resolveDefaultCert := func() {
+		if w.defaultTLSSecretKey == "" {
+			return
+		}
+		namespace, name, ok := splitNamespacedKey(w.defaultTLSSecretKey)
+		if !ok {
+			log.Error().Str("defaultTLSSecret", w.defaultTLSSecretKey).Msg("invalid default tls secret, want namespace/name")
+			return
+		}
+		cert, err := resolveSecret(namespace, name)
 		if err != nil {
-			log.Error().Err(err).Msg("failed to list ingresses")
+			log.Error().Err(err).Str("defaultTLSSecret", w.defaultTLSSecretKey).Msg("unknown or invalid default tls secret")
 			return
 		}
+		w.store.setDefaultCert(cert)
+	}
 
-		for _, ingress := range ingresses {
-			// 构造 IngressPayload 结构
-			ingressPayload := IngressPayload{
-				Ingress:      ingress,
-				ServicePorts: make(map[string]map[string]int),
-			}
-			payload.Ingresses = append(payload.Ingresses, ingressPayload)
-
-			//apiVersion: extensions/v1beta1
-			//kind: Ingress
-			//metadata:
-			//  name: test-ingress
-			//spec:
-			//  backend:
-			//    serviceName: testsvc
-			//    servicePort: 80
-			if ingress.Spec.Backend != nil {
-				// 给 ingressPayload 组装数据
-				addBackend(&ingressPayload, *ingress.Spec.Backend)
-			}
-			//apiVersion: extensions/v1beta1
-			//kind: Ingress
-			//metadata:
-			//  name: test
-			//spec:
-			//  rules:
-			//  - host: foo.bar.com
-			//    http:
-			//      paths:
-			//      - backend:
-			//          serviceName: s1
-			//          servicePort: 80
-			for _, rule := range ingress.Spec.Rules {
-				if rule.HTTP != nil {
-					continue
-				}
-				for _, path := range rule.HTTP.Paths {
-					// 给 ingressPayload 组装数据
-					addBackend(&ingressPayload, path.Backend)
-				}
-			}
+	removeIngressByKey := func(key string) {
+		if w.store.remove(key) {
+			w.emit(Event{Type: IngressDeleted, Key: key})
+		}
+	}
 
-			// 证书处理
-			for _, rec := range ingress.Spec.TLS {
-				if rec.SecretName != "" {
-					// 获取证书对应的 secret
-					secret, err := secretLister.Secrets(ingress.Namespace).Get(rec.SecretName)
-					if err != nil {
-						log.Error().
-							Err(err).
-							Str("namespace", ingress.Namespace).
-							Str("name", rec.SecretName).
-							Msg("unknown secret")
-						continue
-					}
-					// 加载证书
-					cert, err := tls.X509KeyPair(secret.Data["tls.crt"], secret.Data["tls.key"])
-					if err != nil {
-						log.Error().
-							Err(err).
-							Str("namespace", ingress.Namespace).
-							Str("name", rec.SecretName).
-							Msg("invalid tls certificate")
-						continue
-					}
-
-					payload.TLSCertificates[rec.SecretName] = &cert
-				}
+	// recomputeDependents re-evaluates every Ingress that references the
+	// Service/Secret identified by key, e.g. after that Service gained an
+	// endpoint or that Secret's certificate was renewed.
+	recomputeDependents := func(ingressKeys []string, scope *namespaceScope) {
+		for _, ingressKey := range ingressKeys {
+			ns, name, ok := splitNamespacedKey(ingressKey)
+			if !ok {
+				continue
+			}
+			ingress, err := scope.ingresses.Get(ns, name)
+			if err != nil {
+				continue
 			}
+			recomputeIngress(ingress)
 		}
-
-		w.onChange(payload)
 	}
 
 	debounced := debounce.New(time.Second)
-	handler := cache.ResourceEventHandlerFuncs{
+	aggregate := func() {
+		debounced(func() {
+			w.onChange(w.Snapshot())
+		})
+	}
+
+	// notifyLeaderChange bypasses the debounce above: a replica losing
+	// leadership needs onChange to see Payload.IsLeader flip right away
+	// (e.g. so status.Publisher stops writing), not whenever the trailing
+	// edge of some unrelated Ingress/Service/Secret event's debounce fires.
+	notifyLeaderChange := func() {
+		w.onChange(w.Snapshot())
+	}
+
+	if w.leaderElectionCfg != nil {
+		w.leading.Store(false)
+		go w.runLeaderElection(ctx, notifyLeaderChange)
+	}
+
+	ingressHandler := cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			debounced(onChange)
+			if ingress := asIngress(obj, w.useNetworkingV1); ingress != nil {
+				recomputeIngress(ingress)
+				aggregate()
+			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			debounced(onChange)
+			if ingress := asIngress(newObj, w.useNetworkingV1); ingress != nil {
+				recomputeIngress(ingress)
+				aggregate()
+			}
 		},
 		DeleteFunc: func(obj interface{}) {
-			debounced(onChange)
+			if key, ok := keyOf(obj); ok {
+				removeIngressByKey(key)
+				aggregate()
+			}
 		},
 	}
 
-	// 启动 Secret、Ingress、Service 的 Informer，用同一个事件处理器 handler
+	watchedResourceHandler := func(scope *namespaceScope, ingressKeysFor func(key string) []string) cache.ResourceEventHandlerFuncs {
+		onEvent := func(obj interface{}) {
+			key, ok := keyOf(obj)
+			if !ok {
+				return
+			}
+			recomputeDependents(ingressKeysFor(key), scope)
+			aggregate()
+		}
+		return cache.ResourceEventHandlerFuncs{
+			AddFunc:    onEvent,
+			UpdateFunc: func(oldObj, newObj interface{}) { onEvent(newObj) },
+			DeleteFunc: onEvent,
+		}
+	}
+
+	var informerList []cache.SharedIndexInformer
+	for _, scope := range scopes {
+		secretInformer := scope.factory.Core().V1().Secrets().Informer()
+		secretInformer.AddEventHandler(watchedResourceHandler(scope, w.store.ingressKeysForSecret))
+		secretInformer.AddEventHandler(onDefaultKeyChanged(w.defaultTLSSecretKey, func() {
+			resolveDefaultCert()
+			aggregate()
+		}))
+
+		serviceInformer := scope.factory.Core().V1().Services().Informer()
+		serviceInformer.AddEventHandler(watchedResourceHandler(scope, w.store.ingressKeysForService))
+		serviceInformer.AddEventHandler(onDefaultKeyChanged(w.defaultBackendKey, func() {
+			resolveDefaultBackend()
+			aggregate()
+		}))
+
+		ingressInf := ingressInformer(scope.factory, w.useNetworkingV1)
+		ingressInf.AddEventHandler(ingressHandler)
+
+		informerList = append(informerList, secretInformer, serviceInformer, ingressInf)
+	}
+	if classInformer := ingressClassInformer(classFactory, w.useNetworkingV1); classInformer != nil {
+		// IngressClass changes can flip which Ingresses match us, so treat
+		// them like a full re-sync rather than trying to index them.
+		classInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { w.resyncAll(scopes, recomputeIngress); aggregate() },
+			UpdateFunc: func(oldObj, newObj interface{}) { w.resyncAll(scopes, recomputeIngress); aggregate() },
+			DeleteFunc: func(obj interface{}) { w.resyncAll(scopes, recomputeIngress); aggregate() },
+		})
+		informerList = append(informerList, classInformer)
+	}
+
+	// 启动每个 namespace factory 的 Secret、Ingress、Service Informer，以及
+	// 全局的 IngressClass Informer
 	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		informer := factory.Core().V1().Secrets().Informer()
-		informer.AddEventHandler(handler)
-		informer.Run(ctx.Done())
-		wg.Done()
-	}()
-
-	wg.Add(1)
-	go func() {
-		informer := factory.Extensions().V1beta1().Ingresses().Informer()
-		informer.AddEventHandler(handler)
-		informer.Run(ctx.Done())
-		wg.Done()
-	}()
-
-	wg.Add(1)
-	go func() {
-		informer := factory.Core().V1().Services().Informer()
-		informer.AddEventHandler(handler)
-		informer.Run(ctx.Done())
-		wg.Done()
-	}()
+	for _, informer := range informerList {
+		wg.Add(1)
+		go func(informer cache.SharedIndexInformer) {
+			informer.Run(ctx.Done())
+			wg.Done()
+		}(informer)
+	}
+
+	synced := make([]cache.InformerSynced, 0, len(informerList))
+	for _, informer := range informerList {
+		synced = append(synced, informer.HasSynced)
+	}
+	if cache.WaitForCacheSync(ctx.Done(), synced...) {
+		resolveDefaultBackend()
+		resolveDefaultCert()
+		aggregate()
+	}
 
 	wg.Wait()
 	return nil
 }
+
+// onDefaultKeyChanged returns a handler that calls onChange whenever an
+// event's "namespace/name" key matches target; used to keep the
+// controller-wide default backend/certificate in sync with their source
+// Service/Secret. A no-op handler is returned when target is unset.
+func onDefaultKeyChanged(target string, onChange func()) cache.ResourceEventHandlerFuncs {
+	onEvent := func(obj interface{}) {
+		if target == "" {
+			return
+		}
+		if key, ok := keyOf(obj); ok && key == target {
+			onChange()
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    onEvent,
+		UpdateFunc: func(oldObj, newObj interface{}) { onEvent(newObj) },
+		DeleteFunc: onEvent,
+	}
+}
+
+// resyncAll recomputes every Ingress across every scope, used when a
+// cluster-wide change (like an IngressClass edit) can't be narrowed down
+// to a handful of affected Ingresses via the store's reverse indices.
+func (w *Watcher) resyncAll(scopes []*namespaceScope, recomputeIngress func(*networkingv1.Ingress)) {
+	for _, scope := range scopes {
+		ingresses, err := scope.ingresses.List(labels.Everything())
+		if err != nil {
+			log.Error().Err(err).Str("namespace", scope.namespace).Msg("failed to list ingresses for resync")
+			continue
+		}
+		for _, ingress := range ingresses {
+			recomputeIngress(ingress)
+		}
+	}
+}